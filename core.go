@@ -2,10 +2,13 @@ package zapstackdriver
 
 import (
 	"errors"
+	"fmt"
 
 	"cloud.google.com/go/logging"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	mrpb "google.golang.org/genproto/googleapis/api/monitoredres"
 	structpb "google.golang.org/protobuf/types/known/structpb"
 
 	logpb "cloud.google.com/go/logging/apiv2/loggingpb"
@@ -14,23 +17,33 @@ import (
 // Core is the core implements zapcore.Core
 type Core struct {
 	zapcore.LevelEnabler
-	clogger *logging.Logger
-	encoder *StructEncoder
+	sinks          []Sink
+	encoder        *StructEncoder
+	projectID      string
+	traceExtractor TraceExtractor
+
+	serviceContextService string
+	serviceContextVersion string
+	resource              *mrpb.MonitoredResource
+	labels                map[string]string
+
+	atomicLevel     zap.AtomicLevel
+	hasAtomicLevel  bool
+	levelChangeHook func(from, to zapcore.Level)
 }
 
 // CoreOptionFunc -
 type CoreOptionFunc func(*Core) error
 
-// New -
+// New builds a Core that writes every entry to cloudLogger.
 func New(enab zapcore.LevelEnabler, cloudLogger *logging.Logger, options ...CoreOptionFunc) (*Core, error) {
-	c := &Core{
-		LevelEnabler: enab,
-		clogger:      cloudLogger,
-		encoder:      NewStructEncoder(),
-	}
-	if c.clogger == nil {
+	if cloudLogger == nil {
 		return nil, errors.New("Cloud Logger is required")
 	}
+	c, err := NewTee(enab, &cloudLoggingSink{logger: cloudLogger})
+	if err != nil {
+		return nil, err
+	}
 
 	// Run the options on it
 	for _, option := range options {
@@ -42,6 +55,22 @@ func New(enab zapcore.LevelEnabler, cloudLogger *logging.Logger, options ...Core
 	return c, nil
 }
 
+// NewTee builds a Core that fans every entry out to each of sinks, e.g. Cloud
+// Logging in production and a StdoutJSONSink for local/GKE stdout ingestion,
+// without swapping cores between environments.
+func NewTee(enab zapcore.LevelEnabler, sinks ...Sink) (*Core, error) {
+	if len(sinks) == 0 {
+		return nil, errors.New("at least one Sink is required")
+	}
+
+	return &Core{
+		LevelEnabler:   enab,
+		sinks:          sinks,
+		encoder:        NewStructEncoder(),
+		traceExtractor: defaultTraceExtractor,
+	}, nil
+}
+
 // With -
 func (c *Core) With(fields []zapcore.Field) zapcore.Core {
 	clone := c.clone()
@@ -65,12 +94,14 @@ func (c *Core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
 	}
 	if ent.Stack != "" {
 		e2.AddString("stack", ent.Stack)
+	}
 
-		// Add the context for error reporting if this is an error level message
-		if ent.Level >= zapcore.ErrorLevel {
-			addContext(e2, ent)
-			addServiceContext(e2)
-		}
+	// Add the Error Reporting envelope for every error-level message,
+	// regardless of whether a stacktrace was captured.
+	if ent.Level >= zapcore.ErrorLevel {
+		e2.Struct.Fields["@type"] = stringValue(errorReportingType)
+		addContext(e2, ent)
+		addServiceContext(e2, c)
 	}
 	e2.AddString("message", ent.Message)
 
@@ -79,13 +110,27 @@ func (c *Core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
 		Severity:  zapLevelToSeverity(ent.Level),
 		InsertID:  uuid.New().String(),
 		Payload:   e2.Struct,
+		Labels:    c.labels,
+		Resource:  c.resource,
 	}
-	if e2.req != nil {
+	if e2.httpReq != nil {
+		entry.HTTPRequest = e2.httpReq
+	} else if e2.req != nil {
 		entry.HTTPRequest = &logging.HTTPRequest{
 			Request: e2.req,
 		}
 	}
 
+	if tc, ok := c.traceContextFor(e2); ok {
+		if c.projectID != "" {
+			entry.Trace = fmt.Sprintf("projects/%s/traces/%s", c.projectID, tc.traceID)
+		} else {
+			entry.Trace = tc.traceID
+		}
+		entry.SpanID = tc.spanID
+		entry.TraceSampled = tc.sampled
+	}
+
 	if ent.Caller.Defined {
 		// e2.AddString("caller", ent.Caller.String())
 		entry.SourceLocation = &logpb.LogEntrySourceLocation{
@@ -95,85 +140,87 @@ func (c *Core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
 		}
 	}
 
-	c.clogger.Log(entry)
+	for _, sink := range c.sinks {
+		if err := sink.Write(entry); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
-// Sync - call stackdriver logger to 'Flush'
+// Sync flushes every sink.
 func (c *Core) Sync() error {
-	return c.clogger.Flush()
+	for _, sink := range c.sinks {
+		if err := sink.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// addContext - Add's context to the encoder
+// addContext - Add's the Error Reporting context block (reportLocation,
+// httpRequest, user) to the encoder.
 func addContext(e2 *StructEncoder, ent zapcore.Entry) {
-	reportLocation := map[string]*structpb.Value{}
+	ctxFields := map[string]*structpb.Value{}
 
 	// If caller is defined, add the file, line & function
 	if ent.Caller.Defined {
-		contextFields := map[string]*structpb.Value{}
-		contextFields["filePath"] = &structpb.Value{
-			Kind: &structpb.Value_StringValue{
-				StringValue: ent.Caller.File,
-			},
-		}
-		contextFields["lineNumber"] = &structpb.Value{
-			Kind: &structpb.Value_NumberValue{
-				NumberValue: float64(ent.Caller.Line),
-			},
-		}
-		contextFields["functionName"] = &structpb.Value{
-			Kind: &structpb.Value_StringValue{
-				StringValue: ent.Caller.Function,
-			},
-		}
-
-		// Add fields to reportLocation
-		reportLocation["reportLocation"] = &structpb.Value{
-			Kind: &structpb.Value_StructValue{
-				StructValue: &structpb.Struct{
-					Fields: contextFields,
-				},
-			},
-		}
+		ctxFields["reportLocation"] = structValue(map[string]*structpb.Value{
+			"filePath":     stringValue(ent.Caller.File),
+			"lineNumber":   numberValue(float64(ent.Caller.Line)),
+			"functionName": stringValue(ent.Caller.Function),
+		})
 	}
 
-	// Add it to context
-	e2.Struct.Fields["context"] = &structpb.Value{
-		Kind: &structpb.Value_StructValue{
-			StructValue: &structpb.Struct{
-				Fields: reportLocation,
-			},
-		},
+	if hr := httpRequestContext(e2); hr != nil {
+		ctxFields["httpRequest"] = structValue(hr)
 	}
-}
 
-// addServiceContext - Add's service context to the encoder
-func addServiceContext(e2 *StructEncoder) {
-	contextFields := map[string]*structpb.Value{}
-
-	contextFields["service"] = &structpb.Value{
-		Kind: &structpb.Value_StringValue{
-			StringValue: "GO",
-		},
+	if user := userFromRequest(e2); user != "" {
+		ctxFields["user"] = stringValue(user)
 	}
 
-	// Add it to context
-	e2.Struct.Fields["serviceContext"] = &structpb.Value{
-		Kind: &structpb.Value_StructValue{
-			StructValue: &structpb.Struct{
-				Fields: contextFields,
-			},
-		},
-	}
+	e2.Struct.Fields["context"] = structValue(ctxFields)
 }
 
 func (c *Core) clone() *Core {
 	return &Core{
-		LevelEnabler: c.LevelEnabler,
-		encoder:      c.encoder.clone(),
-		clogger:      c.clogger,
+		LevelEnabler:          c.LevelEnabler,
+		encoder:               c.encoder.clone(),
+		sinks:                 c.sinks,
+		projectID:             c.projectID,
+		traceExtractor:        c.traceExtractor,
+		serviceContextService: c.serviceContextService,
+		serviceContextVersion: c.serviceContextVersion,
+		resource:              c.resource,
+		labels:                c.labels,
+		atomicLevel:           c.atomicLevel,
+		hasAtomicLevel:        c.hasAtomicLevel,
+		levelChangeHook:       c.levelChangeHook,
+	}
+}
+
+// traceContextFor resolves the trace/span to stamp on an entry: a
+// context.Context passed via AddReflected takes precedence, falling back to
+// the trace headers on an *http.Request passed the same way.
+func (c *Core) traceContextFor(e2 *StructEncoder) (traceContext, bool) {
+	if e2.ctx != nil {
+		extractor := c.traceExtractor
+		if extractor == nil {
+			extractor = defaultTraceExtractor
+		}
+		if traceID, spanID, sampled := extractor(e2.ctx); traceID != "" {
+			return traceContext{traceID: traceID, spanID: spanID, sampled: sampled}, true
+		}
+	}
+	if e2.req != nil {
+		return extractTraceFromRequest(e2.req)
+	}
+	if e2.httpReq != nil && e2.httpReq.Request != nil {
+		return extractTraceFromRequest(e2.httpReq.Request)
 	}
+	return traceContext{}, false
 }
 
 func zapLevelToSeverity(level zapcore.Level) logging.Severity {
@@ -0,0 +1,77 @@
+package zapstackdriver
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// NewWithAtomicLevel is like New but takes a shared zap.AtomicLevel instead
+// of a bare zapcore.LevelEnabler, so the returned Core can serve LevelHandler
+// and let operators change a running service's verbosity without
+// redeploying.
+func NewWithAtomicLevel(level zap.AtomicLevel, cloudLogger *logging.Logger, options ...CoreOptionFunc) (*Core, error) {
+	c, err := New(level, cloudLogger, options...)
+	if err != nil {
+		return nil, err
+	}
+	c.atomicLevel = level
+	c.hasAtomicLevel = true
+	return c, nil
+}
+
+// WithLevelChangeHook registers fn to be called whenever LevelHandler changes
+// the level (e.g. via a PUT). Core also emits an INFO entry recording the
+// change through its own sinks, so the change shows up in Cloud Logging even
+// if fn doesn't log anything itself.
+func WithLevelChangeHook(fn func(from, to zapcore.Level)) CoreOptionFunc {
+	return func(c *Core) error {
+		c.levelChangeHook = fn
+		return nil
+	}
+}
+
+// LevelHandler serves the AtomicLevel's built-in GET/PUT JSON protocol
+// (see zap.AtomicLevel.ServeHTTP), so operators can inspect and change this
+// Core's log verbosity on a running Cloud Run/GKE service without
+// redeploying. It panics if c wasn't built with NewWithAtomicLevel.
+func (c *Core) LevelHandler() http.Handler {
+	if !c.hasAtomicLevel {
+		panic("zapstackdriver: LevelHandler requires a Core built with NewWithAtomicLevel")
+	}
+	return &levelHandler{core: c}
+}
+
+// levelHandler wraps the AtomicLevel's own handler to notice level changes
+// and report them.
+type levelHandler struct {
+	core *Core
+}
+
+func (h *levelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	before := h.core.atomicLevel.Level()
+	h.core.atomicLevel.ServeHTTP(w, r)
+	after := h.core.atomicLevel.Level()
+
+	if after == before {
+		return
+	}
+	if h.core.levelChangeHook != nil {
+		h.core.levelChangeHook(before, after)
+	}
+	h.core.logLevelChange(before, after)
+}
+
+// logLevelChange writes an audit entry recording a level change directly
+// through the Core's sinks.
+func (c *Core) logLevelChange(from, to zapcore.Level) {
+	_ = c.Write(zapcore.Entry{
+		Level:   zapcore.InfoLevel,
+		Time:    time.Now(),
+		Message: fmt.Sprintf("log level changed from %s to %s", from, to),
+	}, nil)
+}
@@ -0,0 +1,138 @@
+package zapstackdriver
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"go.uber.org/zap"
+)
+
+// loggerCtxKey is the context key Middleware uses to stash the per-request
+// logger, retrievable via FromContext.
+type loggerCtxKey struct{}
+
+// FromContext returns the per-request logger stashed by Middleware. If none
+// is present, it returns fallback.
+func FromContext(ctx context.Context, fallback *zap.Logger) *zap.Logger {
+	if l, ok := ctx.Value(loggerCtxKey{}).(*zap.Logger); ok {
+		return l
+	}
+	return fallback
+}
+
+// MiddlewareOption configures Middleware.
+type MiddlewareOption func(*middlewareConfig)
+
+type middlewareConfig struct {
+	serverIP string
+	message  string
+}
+
+// WithServerIP overrides the IP address reported as ServerIP on every
+// access-log entry. By default Middleware reads it off the connection via
+// http.LocalAddrContextKey.
+func WithServerIP(ip string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.serverIP = ip
+	}
+}
+
+// WithAccessLogMessage overrides the zap message used for the access-log
+// entry emitted once a request completes. Defaults to "request".
+func WithAccessLogMessage(msg string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.message = msg
+	}
+}
+
+// Middleware returns an http middleware that stashes a per-request child
+// logger in the request context (retrievable via FromContext) and, once the
+// wrapped handler returns, emits a single access-log entry whose
+// logging.HTTPRequest is fully populated with status, response size,
+// latency, user agent, and remote/server IP.
+func Middleware(base *zap.Logger, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	cfg := &middlewareConfig{message: "request"}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			logger := base.With(zap.Any("request", r))
+			r = r.WithContext(context.WithValue(r.Context(), loggerCtxKey{}, logger))
+
+			rw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rw, r)
+
+			serverIP := cfg.serverIP
+			if serverIP == "" {
+				serverIP = serverIPFromRequest(r)
+			}
+
+			logger.Info(cfg.message, zap.Any("httpRequest", &logging.HTTPRequest{
+				Request:      r,
+				Status:       rw.status,
+				ResponseSize: rw.size,
+				Latency:      time.Since(start),
+				RemoteIP:     remoteIP(r),
+				LocalIP:      serverIP,
+			}))
+		})
+	}
+}
+
+// statusResponseWriter wraps an http.ResponseWriter to capture the status
+// code and response size written by the handler.
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (w *statusResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher, forwarding to the wrapped ResponseWriter if
+// it supports it and doing nothing otherwise.
+func (w *statusResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// remoteIP strips the port off r.RemoteAddr, falling back to the raw value
+// if it isn't a host:port pair.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// serverIPFromRequest reads the connection's local address, which net/http
+// stashes on the request context under http.LocalAddrContextKey.
+func serverIPFromRequest(r *http.Request) string {
+	addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
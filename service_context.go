@@ -0,0 +1,122 @@
+package zapstackdriver
+
+import (
+	mrpb "google.golang.org/genproto/googleapis/api/monitoredres"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+)
+
+// WithServiceContext sets the service name and version reported in the
+// serviceContext of the Error Reporting envelope, which is added only to
+// entries at or above ErrorLevel. Error Reporting groups errors by service + version,
+// so without this every deployment of every service reports under the same
+// "GO" bucket.
+func WithServiceContext(service, version string) CoreOptionFunc {
+	return func(c *Core) error {
+		c.serviceContextService = service
+		c.serviceContextVersion = version
+		return nil
+	}
+}
+
+// WithResource sets the MonitoredResource stamped on every entry, and
+// supplies the serviceContext.resourceType reported in the Error Reporting
+// envelope.
+func WithResource(resource *mrpb.MonitoredResource) CoreOptionFunc {
+	return func(c *Core) error {
+		c.resource = resource
+		return nil
+	}
+}
+
+// WithLabels sets the labels stamped on every entry written by the Core.
+func WithLabels(labels map[string]string) CoreOptionFunc {
+	return func(c *Core) error {
+		c.labels = labels
+		return nil
+	}
+}
+
+// errorReportingType is the Error Reporting envelope's @type value; its
+// presence is what makes the Logging UI surface an entry as an error group.
+const errorReportingType = "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"
+
+// addServiceContext adds the serviceContext block of the Error Reporting
+// envelope.
+func addServiceContext(e2 *StructEncoder, c *Core) {
+	service := c.serviceContextService
+	if service == "" {
+		service = "GO"
+	}
+
+	fields := map[string]*structpb.Value{
+		"service": stringValue(service),
+	}
+	if c.serviceContextVersion != "" {
+		fields["version"] = stringValue(c.serviceContextVersion)
+	}
+	if c.resource != nil && c.resource.Type != "" {
+		fields["resourceType"] = stringValue(c.resource.Type)
+	}
+
+	e2.Struct.Fields["serviceContext"] = structValue(fields)
+}
+
+// httpRequestContext builds the context.httpRequest block Error Reporting
+// expects, from whichever of the encoder's request fields is populated.
+func httpRequestContext(e2 *StructEncoder) map[string]*structpb.Value {
+	req := e2.req
+	status := 0
+	if e2.httpReq != nil {
+		req = e2.httpReq.Request
+		status = e2.httpReq.Status
+	}
+	if req == nil {
+		return nil
+	}
+
+	fields := map[string]*structpb.Value{
+		"method": stringValue(req.Method),
+		"url":    stringValue(req.URL.String()),
+	}
+	if ua := req.UserAgent(); ua != "" {
+		fields["userAgent"] = stringValue(ua)
+	}
+	if ref := req.Referer(); ref != "" {
+		fields["referrer"] = stringValue(ref)
+	}
+	if status != 0 {
+		fields["responseStatusCode"] = numberValue(float64(status))
+	}
+	if ip := remoteIP(req); ip != "" {
+		fields["remoteIp"] = stringValue(ip)
+	}
+	return fields
+}
+
+// userFromRequest reports the basic-auth username, if any, as
+// context.user. There's no other user identity available to this package.
+func userFromRequest(e2 *StructEncoder) string {
+	req := e2.req
+	if e2.httpReq != nil {
+		req = e2.httpReq.Request
+	}
+	if req == nil {
+		return ""
+	}
+	if user, _, ok := req.BasicAuth(); ok {
+		return user
+	}
+	return ""
+}
+
+func stringValue(v string) *structpb.Value {
+	return &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: v}}
+}
+
+func numberValue(v float64) *structpb.Value {
+	return &structpb.Value{Kind: &structpb.Value_NumberValue{NumberValue: v}}
+}
+
+func structValue(fields map[string]*structpb.Value) *structpb.Value {
+	return &structpb.Value{Kind: &structpb.Value_StructValue{StructValue: &structpb.Struct{Fields: fields}}}
+}
@@ -0,0 +1,294 @@
+package zapstackdriver
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"google.golang.org/protobuf/encoding/protojson"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+)
+
+// Sink is the destination Core writes entries to. The default is
+// cloudLoggingSink; StdoutJSONSink and AsyncBufferedSink let entries also
+// (or instead) go to local stdout or be batched, without swapping cores.
+type Sink interface {
+	Write(entry logging.Entry) error
+	Flush() error
+}
+
+// cloudLoggingSink is the Sink backing the original, single-logger Core
+// built by New.
+type cloudLoggingSink struct {
+	logger *logging.Logger
+}
+
+func (s *cloudLoggingSink) Write(entry logging.Entry) error {
+	s.logger.Log(entry)
+	return nil
+}
+
+func (s *cloudLoggingSink) Flush() error {
+	return s.logger.Flush()
+}
+
+// StdoutJSONSink writes entries as newline-delimited JSON, using the field
+// names Cloud Logging's agent recognizes in structured container logs
+// (https://cloud.google.com/logging/docs/structured-logging). It's meant for
+// local development and for GKE/Cloud Run setups that ingest stdout rather
+// than calling the Logging API directly.
+type StdoutJSONSink struct {
+	w io.Writer
+}
+
+// NewStdoutJSONSink returns a StdoutJSONSink that writes to w.
+func NewStdoutJSONSink(w io.Writer) *StdoutJSONSink {
+	return &StdoutJSONSink{w: w}
+}
+
+// Write marshals entry's payload and well-known fields to a single JSON line.
+func (s *StdoutJSONSink) Write(entry logging.Entry) error {
+	fields := map[string]interface{}{}
+	if st, ok := entry.Payload.(*structpb.Struct); ok {
+		b, err := protojson.Marshal(st)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(b, &fields); err != nil {
+			return err
+		}
+	}
+
+	fields["severity"] = entry.Severity.String()
+	if !entry.Timestamp.IsZero() {
+		fields["timestamp"] = entry.Timestamp.Format(time.RFC3339Nano)
+	}
+	if entry.InsertID != "" {
+		fields["logging.googleapis.com/insertId"] = entry.InsertID
+	}
+	if entry.Trace != "" {
+		fields["logging.googleapis.com/trace"] = entry.Trace
+	}
+	if entry.SpanID != "" {
+		fields["logging.googleapis.com/spanId"] = entry.SpanID
+	}
+	if entry.TraceSampled {
+		fields["logging.googleapis.com/trace_sampled"] = true
+	}
+	if len(entry.Labels) > 0 {
+		fields["logging.googleapis.com/labels"] = entry.Labels
+	}
+	if entry.HTTPRequest != nil {
+		fields["httpRequest"] = httpRequestJSON(entry.HTTPRequest)
+	}
+
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	_, err = s.w.Write(b)
+	return err
+}
+
+// Flush syncs the underlying writer if it supports it (e.g. *os.File).
+func (s *StdoutJSONSink) Flush() error {
+	if f, ok := s.w.(interface{ Sync() error }); ok {
+		return f.Sync()
+	}
+	return nil
+}
+
+func httpRequestJSON(hr *logging.HTTPRequest) map[string]interface{} {
+	m := map[string]interface{}{}
+	if hr.Request != nil {
+		m["requestMethod"] = hr.Request.Method
+		m["requestUrl"] = hr.Request.URL.String()
+		if ua := hr.Request.UserAgent(); ua != "" {
+			m["userAgent"] = ua
+		}
+	}
+	if hr.Status != 0 {
+		m["status"] = hr.Status
+	}
+	if hr.ResponseSize != 0 {
+		m["responseSize"] = hr.ResponseSize
+	}
+	if hr.Latency != 0 {
+		m["latency"] = hr.Latency.String()
+	}
+	if hr.RemoteIP != "" {
+		m["remoteIp"] = hr.RemoteIP
+	}
+	if hr.LocalIP != "" {
+		m["serverIp"] = hr.LocalIP
+	}
+	return m
+}
+
+// AsyncBufferedSinkOption configures an AsyncBufferedSink.
+type AsyncBufferedSinkOption func(*AsyncBufferedSink)
+
+// WithQueueSize sets how many entries may be buffered before Write starts
+// dropping them. Defaults to 1000.
+func WithQueueSize(n int) AsyncBufferedSinkOption {
+	return func(s *AsyncBufferedSink) { s.queue = make(chan logging.Entry, n) }
+}
+
+// WithFlushInterval sets how often the sink flushes the wrapped Sink on a
+// timer, independent of WithMaxBatchSize. Defaults to 5s.
+func WithFlushInterval(d time.Duration) AsyncBufferedSinkOption {
+	return func(s *AsyncBufferedSink) { s.interval = d }
+}
+
+// WithMaxBatchSize sets how many writes accumulate before the sink flushes
+// the wrapped Sink early, without waiting for the timer. Defaults to 100.
+func WithMaxBatchSize(n int) AsyncBufferedSinkOption {
+	return func(s *AsyncBufferedSink) { s.batch = n }
+}
+
+// AsyncBufferedSink wraps another Sink, delivering entries from a background
+// goroutine so Core.Write never blocks on it. Entries are handed to the
+// wrapped Sink as they arrive and flushed either every WithMaxBatchSize
+// writes or every WithFlushInterval, whichever comes first. If the queue is
+// full, Write drops the entry and counts it in Dropped rather than block the
+// caller.
+type AsyncBufferedSink struct {
+	sink     Sink
+	queue    chan logging.Entry
+	interval time.Duration
+	batch    int
+
+	flushReq  chan chan struct{}
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+	closed    int32
+
+	dropped int64
+}
+
+// NewAsyncBufferedSink starts the background goroutine and returns the sink.
+// Flush (e.g. via the owning Core's Sync) drains it on demand and can be
+// called any number of times over the sink's life; call Close once, during
+// shutdown, to stop the background goroutine for good.
+func NewAsyncBufferedSink(sink Sink, opts ...AsyncBufferedSinkOption) *AsyncBufferedSink {
+	s := &AsyncBufferedSink{
+		sink:     sink,
+		queue:    make(chan logging.Entry, 1000),
+		interval: 5 * time.Second,
+		batch:    100,
+		flushReq: make(chan chan struct{}),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	go s.loop()
+	return s
+}
+
+func (s *AsyncBufferedSink) loop() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	pending := 0
+	for {
+		select {
+		case e := <-s.queue:
+			if err := s.sink.Write(e); err == nil {
+				pending++
+			}
+			if pending >= s.batch {
+				s.sink.Flush()
+				pending = 0
+			}
+		case <-ticker.C:
+			if pending > 0 {
+				s.sink.Flush()
+				pending = 0
+			}
+		case ack := <-s.flushReq:
+			for drained := false; !drained; {
+				select {
+				case e := <-s.queue:
+					if err := s.sink.Write(e); err == nil {
+						pending++
+					}
+				default:
+					drained = true
+				}
+			}
+			if pending > 0 {
+				s.sink.Flush()
+				pending = 0
+			}
+			close(ack)
+		case <-s.done:
+			for {
+				select {
+				case e := <-s.queue:
+					s.sink.Write(e)
+				default:
+					s.sink.Flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// Write enqueues entry for asynchronous delivery. If the queue is full, or
+// Close has already been called, the entry is dropped and counted in
+// Dropped instead of blocking the caller or delivering silently.
+func (s *AsyncBufferedSink) Write(entry logging.Entry) error {
+	if atomic.LoadInt32(&s.closed) != 0 {
+		atomic.AddInt64(&s.dropped, 1)
+		return nil
+	}
+	select {
+	case s.queue <- entry:
+	default:
+		atomic.AddInt64(&s.dropped, 1)
+	}
+	return nil
+}
+
+// Flush drains any queued entries into the wrapped Sink without stopping the
+// background goroutine, so it's safe to call repeatedly over the process's
+// life (e.g. from a periodic or panic-recovery logger.Sync()). It is a no-op
+// once Close has been called.
+func (s *AsyncBufferedSink) Flush() error {
+	ack := make(chan struct{})
+	select {
+	case s.flushReq <- ack:
+		<-ack
+	case <-s.stopped:
+	}
+	return nil
+}
+
+// Close stops the background goroutine after draining and flushing any
+// queued entries. Call it once, on shutdown; after Close, Write drops every
+// entry. It is safe to call more than once.
+func (s *AsyncBufferedSink) Close() error {
+	s.closeOnce.Do(func() {
+		atomic.StoreInt32(&s.closed, 1)
+		close(s.done)
+	})
+	<-s.stopped
+	return nil
+}
+
+// Dropped returns the number of entries dropped so far because the queue was
+// full or Close had already been called.
+func (s *AsyncBufferedSink) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
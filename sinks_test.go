@@ -0,0 +1,160 @@
+package zapstackdriver
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging"
+)
+
+// recordingSink records every entry and Flush call, signaling notify after
+// each Write so tests can synchronize with the background goroutine instead
+// of sleeping.
+type recordingSink struct {
+	notify chan struct{}
+
+	mu      sync.Mutex
+	entries []logging.Entry
+	flushes int
+}
+
+func newRecordingSink() *recordingSink {
+	return &recordingSink{notify: make(chan struct{})}
+}
+
+func (s *recordingSink) Write(e logging.Entry) error {
+	s.mu.Lock()
+	s.entries = append(s.entries, e)
+	s.mu.Unlock()
+	s.notify <- struct{}{}
+	return nil
+}
+
+func (s *recordingSink) Flush() error {
+	s.mu.Lock()
+	s.flushes++
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *recordingSink) counts() (entries, flushes int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries), s.flushes
+}
+
+// blockingSink blocks every Write until release is closed, so a test can
+// hold the AsyncBufferedSink's background goroutine busy while it fills the
+// queue up.
+type blockingSink struct {
+	started chan struct{}
+	release chan struct{}
+	writes  int32
+}
+
+func newBlockingSink() *blockingSink {
+	return &blockingSink{started: make(chan struct{}, 1), release: make(chan struct{})}
+}
+
+func (s *blockingSink) Write(logging.Entry) error {
+	atomic.AddInt32(&s.writes, 1)
+	select {
+	case s.started <- struct{}{}:
+	default:
+	}
+	<-s.release
+	return nil
+}
+
+func (s *blockingSink) Flush() error { return nil }
+
+func TestAsyncBufferedSinkOverflowDrops(t *testing.T) {
+	bs := newBlockingSink()
+	sink := NewAsyncBufferedSink(bs, WithQueueSize(1))
+	defer sink.Close()
+
+	// Entry A is picked up by the background goroutine immediately and
+	// blocks it in bs.Write, draining the queue back to empty.
+	if err := sink.Write(logging.Entry{}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	<-bs.started
+
+	// Entry B fills the size-1 queue.
+	if err := sink.Write(logging.Entry{}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	// Entry C finds the queue full and must be dropped.
+	if err := sink.Write(logging.Entry{}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	if got := sink.Dropped(); got != 1 {
+		t.Fatalf("Dropped() = %d, want 1", got)
+	}
+
+	close(bs.release)
+}
+
+func TestAsyncBufferedSinkFlushIsNonDestructive(t *testing.T) {
+	rec := newRecordingSink()
+	sink := NewAsyncBufferedSink(rec, WithMaxBatchSize(1000), WithFlushInterval(time.Hour))
+	defer sink.Close()
+
+	if err := sink.Write(logging.Entry{}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	<-rec.notify
+
+	if err := sink.Flush(); err != nil {
+		t.Fatalf("first Flush: %v", err)
+	}
+	if _, flushes := rec.counts(); flushes != 1 {
+		t.Fatalf("flushes after first Flush = %d, want 1", flushes)
+	}
+
+	// A second Write/Flush cycle must still work: Flush must not have torn
+	// down the background goroutine.
+	if err := sink.Write(logging.Entry{}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	<-rec.notify
+
+	if err := sink.Flush(); err != nil {
+		t.Fatalf("second Flush: %v", err)
+	}
+	entries, flushes := rec.counts()
+	if entries != 2 {
+		t.Fatalf("entries = %d, want 2", entries)
+	}
+	if flushes != 2 {
+		t.Fatalf("flushes after second Flush = %d, want 2", flushes)
+	}
+}
+
+func TestAsyncBufferedSinkDropsAfterClose(t *testing.T) {
+	rec := newRecordingSink()
+	sink := NewAsyncBufferedSink(rec)
+
+	if err := sink.Write(logging.Entry{}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	<-rec.notify
+
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if err := sink.Write(logging.Entry{}); err != nil {
+		t.Fatalf("Write after Close: %v", err)
+	}
+
+	if got := sink.Dropped(); got != 1 {
+		t.Fatalf("Dropped() = %d, want 1 (the post-Close write)", got)
+	}
+	if entries, _ := rec.counts(); entries != 1 {
+		t.Fatalf("entries = %d, want 1 (post-Close write must not reach the wrapped sink)", entries)
+	}
+}
@@ -1,19 +1,23 @@
 package zapstackdriver
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
+	"cloud.google.com/go/logging"
 	"go.uber.org/zap/buffer"
 	"go.uber.org/zap/zapcore"
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 	structpb "google.golang.org/protobuf/types/known/structpb"
 )
 
+var _bufferPool = buffer.NewPool()
+
 // listValueEncoder wrapped structpb., implements zapcore.ArrayEncoder
 type listValueEncoder struct {
 	*structpb.ListValue
@@ -224,7 +228,25 @@ func (l *listValueEncoder) AppendReflected(v interface{}) error {
 // https://github.com/golang/protobuf/blob/master/ptypes/struct/struct.proto
 type StructEncoder struct {
 	*structpb.Struct
-	req *http.Request
+	req     *http.Request
+	ctx     context.Context
+	httpReq *logging.HTTPRequest
+
+	// namespaceKeys/namespaces track the stack of currently open
+	// namespaces in parallel: namespaces[i] is the *structpb.Struct that
+	// OpenNamespace(namespaceKeys[i]) pushed. AddXxx routes through
+	// currentFields(), which targets the innermost one.
+	namespaceKeys []string
+	namespaces    []*structpb.Struct
+}
+
+// currentFields returns the Fields map that AddXxx should write into: the
+// innermost open namespace, or the top-level Struct if none is open.
+func (e *StructEncoder) currentFields() map[string]*structpb.Value {
+	if n := len(e.namespaces); n > 0 {
+		return e.namespaces[n-1].Fields
+	}
+	return e.Struct.Fields
 }
 
 // NewStructEncoder -
@@ -243,7 +265,7 @@ func (e *StructEncoder) AddArray(key string, v zapcore.ArrayMarshaler) error {
 	if err != nil {
 		return err
 	}
-	e.Fields[key] = &structpb.Value{
+	e.currentFields()[key] = &structpb.Value{
 		Kind: &structpb.Value_ListValue{
 			ListValue: enc.ListValue,
 		},
@@ -258,7 +280,7 @@ func (e *StructEncoder) AddObject(key string, v zapcore.ObjectMarshaler) error {
 	if err != nil {
 		return err
 	}
-	e.Fields[key] = &structpb.Value{
+	e.currentFields()[key] = &structpb.Value{
 		Kind: &structpb.Value_StructValue{
 			StructValue: enc.Struct,
 		},
@@ -276,7 +298,7 @@ func (e *StructEncoder) AddByteString(key string, v []byte) { e.AddString(key, s
 
 // AddBool -
 func (e *StructEncoder) AddBool(key string, v bool) {
-	e.Fields[key] = &structpb.Value{
+	e.currentFields()[key] = &structpb.Value{
 		Kind: &structpb.Value_BoolValue{
 			BoolValue: v,
 		},
@@ -294,7 +316,7 @@ func (e *StructEncoder) AddDuration(key string, v time.Duration) { e.AddFloat64(
 
 // AddFloat64 -
 func (e *StructEncoder) AddFloat64(key string, v float64) {
-	e.Fields[key] = &structpb.Value{
+	e.currentFields()[key] = &structpb.Value{
 		Kind: &structpb.Value_NumberValue{
 			NumberValue: v,
 		},
@@ -321,7 +343,7 @@ func (e *StructEncoder) AddInt8(key string, v int8) { e.AddFloat64(key, float64(
 
 // AddString -
 func (e *StructEncoder) AddString(key, v string) {
-	e.Fields[key] = &structpb.Value{
+	e.currentFields()[key] = &structpb.Value{
 		Kind: &structpb.Value_StringValue{
 			StringValue: v,
 		},
@@ -353,7 +375,14 @@ func (e *StructEncoder) AddUintptr(key string, v uintptr) { e.AddUint64(key, uin
 // and allocation-heavy.
 func (e *StructEncoder) AddReflected(key string, v interface{}) error {
 	if sv, ok := v.(*structpb.Value); ok {
-		e.Fields[key] = sv
+		e.currentFields()[key] = sv
+		return nil
+	}
+
+	// a fully-populated HTTPRequest (e.g. from Middleware) is stored and
+	// used as-is, taking precedence over the raw *http.Request case below
+	if sv, ok := v.(*logging.HTTPRequest); ok {
+		e.httpReq = sv
 		return nil
 	}
 
@@ -363,6 +392,13 @@ func (e *StructEncoder) AddReflected(key string, v interface{}) error {
 		return nil
 	}
 
+	// will store context separately, so Core.Write can pull trace/span
+	// correlation fields out of it
+	if sv, ok := v.(context.Context); ok {
+		e.ctx = sv
+		return nil
+	}
+
 	marshaled, err := json.Marshal(v)
 	if err != nil {
 		return err
@@ -376,7 +412,7 @@ func (e *StructEncoder) AddReflected(key string, v interface{}) error {
 	if err != nil {
 		return err
 	}
-	e.Fields[key] = &structpb.Value{
+	e.currentFields()[key] = &structpb.Value{
 		Kind: &structpb.Value_StructValue{
 			StructValue: st,
 		},
@@ -431,7 +467,12 @@ func jsonValueToStructValue(v interface{}) (*structpb.Value, error) {
 // be added. Applications can use namespaces to prevent key collisions when
 // injecting loggers into sub-components or third-party libraries.
 func (e *StructEncoder) OpenNamespace(key string) {
-
+	ns := &structpb.Struct{Fields: map[string]*structpb.Value{}}
+	e.currentFields()[key] = &structpb.Value{
+		Kind: &structpb.Value_StructValue{StructValue: ns},
+	}
+	e.namespaceKeys = append(e.namespaceKeys, key)
+	e.namespaces = append(e.namespaces, ns)
 }
 
 // Clone -
@@ -444,12 +485,45 @@ func (e *StructEncoder) clone() *StructEncoder {
 	if ce.Fields == nil {
 		ce.Fields = map[string]*structpb.Value{}
 	}
-	return &StructEncoder{Struct: ce}
+	clone := &StructEncoder{Struct: ce, req: e.req, ctx: e.ctx, httpReq: e.httpReq}
+
+	// Re-walk the namespace stack inside the cloned tree so the clone's
+	// currentFields() targets the same nested structs as the original,
+	// not the ones proto.Clone just copied away from.
+	cur := ce
+	for _, key := range e.namespaceKeys {
+		ns := cur.Fields[key].GetStructValue()
+		clone.namespaceKeys = append(clone.namespaceKeys, key)
+		clone.namespaces = append(clone.namespaces, ns)
+		cur = ns
+	}
+	return clone
 }
 
-// EncodeEntry -
+// EncodeEntry implements zapcore.Encoder, making StructEncoder usable with a
+// vanilla zapcore.NewCore (e.g. for local stdout logging) rather than only
+// through this package's own Core.
 func (e *StructEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
-	return nil, errors.New("NOT IMPLEMENTED")
+	e2, err := e.encodeEntry(ent, fields)
+	if err != nil {
+		return nil, err
+	}
+	if ent.Stack != "" {
+		e2.AddString("stack", ent.Stack)
+	}
+	e2.AddString("message", ent.Message)
+	e2.AddString("severity", zapLevelToSeverity(ent.Level).String())
+	e2.AddString("timestamp", ent.Time.Format(time.RFC3339Nano))
+
+	b, err := protojson.Marshal(e2.Struct)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := _bufferPool.Get()
+	buf.Write(b)
+	buf.AppendByte('\n')
+	return buf, nil
 }
 
 func (e *StructEncoder) encodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*StructEncoder, error) {
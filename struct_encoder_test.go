@@ -0,0 +1,107 @@
+package zapstackdriver
+
+import (
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestStructEncoderOpenNamespaceNested(t *testing.T) {
+	e := NewStructEncoder()
+	e.AddString("top", "v")
+	e.OpenNamespace("ns1")
+	e.AddString("a", "1")
+	e.OpenNamespace("ns2")
+	e.AddString("b", "2")
+
+	if got := e.Struct.Fields["top"].GetStringValue(); got != "v" {
+		t.Fatalf("top = %q, want %q", got, "v")
+	}
+
+	ns1 := e.Struct.Fields["ns1"].GetStructValue()
+	if ns1 == nil {
+		t.Fatal("ns1 field is not a struct value")
+	}
+	if got := ns1.Fields["a"].GetStringValue(); got != "1" {
+		t.Fatalf("ns1.a = %q, want %q", got, "1")
+	}
+
+	ns2 := ns1.Fields["ns2"].GetStructValue()
+	if ns2 == nil {
+		t.Fatal("ns1.ns2 field is not a struct value")
+	}
+	if got := ns2.Fields["b"].GetStringValue(); got != "2" {
+		t.Fatalf("ns1.ns2.b = %q, want %q", got, "2")
+	}
+
+	// b must only live under ns1.ns2, not at the top level or directly
+	// under ns1.
+	if _, ok := e.Struct.Fields["b"]; ok {
+		t.Fatal("b leaked to the top-level struct")
+	}
+	if _, ok := ns1.Fields["b"]; ok {
+		t.Fatal("b leaked to ns1 instead of staying under ns1.ns2")
+	}
+}
+
+func TestStructEncoderCloneAfterOpenNamespace(t *testing.T) {
+	e := NewStructEncoder()
+	e.OpenNamespace("ns")
+	e.AddString("a", "1")
+
+	clone := e.clone()
+	clone.AddString("b", "2")
+
+	ns := clone.Struct.Fields["ns"].GetStructValue()
+	if ns == nil {
+		t.Fatal("clone's ns field is not a struct value")
+	}
+	if got := ns.Fields["a"].GetStringValue(); got != "1" {
+		t.Fatalf("clone ns.a = %q, want %q", got, "1")
+	}
+	if got := ns.Fields["b"].GetStringValue(); got != "2" {
+		t.Fatalf("clone ns.b = %q, want %q (With after OpenNamespace should stay inside the namespace)", got, "2")
+	}
+
+	// The original encoder must be unaffected by fields added to the clone.
+	origNs := e.Struct.Fields["ns"].GetStructValue()
+	if _, ok := origNs.Fields["b"]; ok {
+		t.Fatal("field added to the clone leaked back into the original encoder")
+	}
+}
+
+func TestStructEncoderArrayInsideNamespace(t *testing.T) {
+	e := NewStructEncoder()
+	e.OpenNamespace("ns")
+	err := e.AddArray("nums", zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+		enc.AppendInt(1)
+		enc.AppendInt(2)
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("AddArray: %v", err)
+	}
+
+	ns := e.Struct.Fields["ns"].GetStructValue()
+	if ns == nil {
+		t.Fatal("ns field is not a struct value")
+	}
+	list := ns.Fields["nums"].GetListValue()
+	if list == nil {
+		t.Fatal("ns.nums field is not a list value")
+	}
+	if len(list.Values) != 2 {
+		t.Fatalf("len(ns.nums) = %d, want 2", len(list.Values))
+	}
+	if got := list.Values[0].GetNumberValue(); got != 1 {
+		t.Fatalf("ns.nums[0] = %v, want 1", got)
+	}
+	if got := list.Values[1].GetNumberValue(); got != 2 {
+		t.Fatalf("ns.nums[1] = %v, want 2", got)
+	}
+
+	// The array must not also appear at the top level.
+	if _, ok := e.Struct.Fields["nums"]; ok {
+		t.Fatal("nums leaked to the top-level struct")
+	}
+}
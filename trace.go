@@ -0,0 +1,104 @@
+package zapstackdriver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceExtractor pulls the trace ID, span ID, and sampling decision out of a
+// context.Context so Write can populate the Trace/SpanID/TraceSampled fields
+// on the logging.Entry, letting entries be joined against Cloud Trace /
+// OpenTelemetry spans in the console.
+type TraceExtractor func(ctx context.Context) (traceID, spanID string, sampled bool)
+
+// defaultTraceExtractor reads the active span context via the OpenTelemetry
+// SDK.
+func defaultTraceExtractor(ctx context.Context) (traceID, spanID string, sampled bool) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return "", "", false
+	}
+	return sc.TraceID().String(), sc.SpanID().String(), sc.IsSampled()
+}
+
+// WithProjectID sets the GCP project used to build the
+// `projects/<proj>/traces/<traceID>` value stored in logging.Entry.Trace.
+func WithProjectID(projectID string) CoreOptionFunc {
+	return func(c *Core) error {
+		c.projectID = projectID
+		return nil
+	}
+}
+
+// WithTraceExtractor overrides how trace/span IDs are derived from a
+// context.Context passed to AddReflected. It defaults to
+// go.opentelemetry.io/otel/trace.SpanContextFromContext.
+func WithTraceExtractor(fn TraceExtractor) CoreOptionFunc {
+	return func(c *Core) error {
+		c.traceExtractor = fn
+		return nil
+	}
+}
+
+// traceContext is the trace/span pair to stamp onto an entry, however it was
+// derived (from a context.Context or from an incoming request's headers).
+type traceContext struct {
+	traceID string
+	spanID  string
+	sampled bool
+}
+
+// extractTraceFromRequest parses the W3C `traceparent` header, falling back
+// to Google's legacy `X-Cloud-Trace-Context` header.
+func extractTraceFromRequest(req *http.Request) (traceContext, bool) {
+	if tp := req.Header.Get("traceparent"); tp != "" {
+		if tc, ok := parseTraceparent(tp); ok {
+			return tc, true
+		}
+	}
+	if xctc := req.Header.Get("X-Cloud-Trace-Context"); xctc != "" {
+		if tc, ok := parseCloudTraceContext(xctc); ok {
+			return tc, true
+		}
+	}
+	return traceContext{}, false
+}
+
+// parseTraceparent parses the W3C Trace Context header, e.g.
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func parseTraceparent(tp string) (traceContext, bool) {
+	parts := strings.Split(tp, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return traceContext{}, false
+	}
+	return traceContext{
+		traceID: parts[1],
+		spanID:  parts[2],
+		sampled: parts[3] == "01",
+	}, true
+}
+
+// parseCloudTraceContext parses the legacy
+// "TRACE_ID/SPAN_ID;o=TRACE_TRUE" header format.
+func parseCloudTraceContext(xctc string) (traceContext, bool) {
+	main := xctc
+	sampled := false
+	if i := strings.Index(xctc, ";"); i >= 0 {
+		main = xctc[:i]
+		sampled = strings.Contains(xctc[i+1:], "o=1")
+	}
+	parts := strings.SplitN(main, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return traceContext{}, false
+	}
+	spanID := parts[1]
+	if n, err := strconv.ParseUint(parts[1], 10, 64); err == nil {
+		spanID = fmt.Sprintf("%016x", n)
+	}
+	return traceContext{traceID: parts[0], spanID: spanID, sampled: sampled}, true
+}
@@ -0,0 +1,94 @@
+package zapstackdriver
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		want   traceContext
+		wantOK bool
+	}{
+		{
+			name:   "sampled",
+			in:     "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			want:   traceContext{traceID: "4bf92f3577b34da6a3ce929d0e0e4736", spanID: "00f067aa0ba902b7", sampled: true},
+			wantOK: true,
+		},
+		{
+			name:   "not sampled",
+			in:     "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			want:   traceContext{traceID: "4bf92f3577b34da6a3ce929d0e0e4736", spanID: "00f067aa0ba902b7", sampled: false},
+			wantOK: true,
+		},
+		{name: "too few parts", in: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7"},
+		{name: "short trace id", in: "00-aaaa-00f067aa0ba902b7-01"},
+		{name: "short span id", in: "00-4bf92f3577b34da6a3ce929d0e0e4736-aaaa-01"},
+		{name: "empty", in: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseTraceparent(tt.in)
+			if ok != tt.wantOK {
+				t.Fatalf("parseTraceparent(%q) ok = %v, want %v", tt.in, ok, tt.wantOK)
+			}
+			if ok && got != tt.want {
+				t.Fatalf("parseTraceparent(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCloudTraceContext(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		want   traceContext
+		wantOK bool
+	}{
+		{
+			name:   "sampled numeric span",
+			in:     "105445aa7843bc8bf206b12000100000/1;o=1",
+			want:   traceContext{traceID: "105445aa7843bc8bf206b12000100000", spanID: fmt.Sprintf("%016x", 1), sampled: true},
+			wantOK: true,
+		},
+		{
+			name:   "not sampled",
+			in:     "105445aa7843bc8bf206b12000100000/1;o=0",
+			want:   traceContext{traceID: "105445aa7843bc8bf206b12000100000", spanID: fmt.Sprintf("%016x", 1), sampled: false},
+			wantOK: true,
+		},
+		{
+			name:   "no options segment defaults to unsampled",
+			in:     "105445aa7843bc8bf206b12000100000/42",
+			want:   traceContext{traceID: "105445aa7843bc8bf206b12000100000", spanID: fmt.Sprintf("%016x", 42), sampled: false},
+			wantOK: true,
+		},
+		{
+			name:   "non-numeric span id kept as-is",
+			in:     "105445aa7843bc8bf206b12000100000/abc;o=1",
+			want:   traceContext{traceID: "105445aa7843bc8bf206b12000100000", spanID: "abc", sampled: true},
+			wantOK: true,
+		},
+		{name: "missing slash", in: "105445aa7843bc8bf206b12000100000"},
+		{name: "empty trace id", in: "/1"},
+		{name: "empty span id", in: "105445aa7843bc8bf206b12000100000/"},
+		{name: "empty", in: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseCloudTraceContext(tt.in)
+			if ok != tt.wantOK {
+				t.Fatalf("parseCloudTraceContext(%q) ok = %v, want %v", tt.in, ok, tt.wantOK)
+			}
+			if ok && got != tt.want {
+				t.Fatalf("parseCloudTraceContext(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}